@@ -9,16 +9,30 @@ import (
 
 func main() {
 	terminalHandler := terminal.NewHandler()
-	
-	http.Handle("/ws/terminal", terminalHandler)
-	
-	http.Handle("/", http.FileServer(http.Dir("./static/")))
-	
-	log.Println("Server starting on :8080")
-	log.Println("Terminal WebSocket endpoint: ws://localhost:8080/ws/terminal")
+
+	mux := http.NewServeMux()
+	mux.Handle("/ws/terminal", terminalHandler)
+	mux.HandleFunc("/ws/terminal/join/", terminalHandler.ServeJoin)
+	mux.HandleFunc("/recordings/", terminalHandler.ServeRecording)
+	mux.HandleFunc("/replay/", terminalHandler.ServeReplay)
+	mux.Handle("/", http.FileServer(http.Dir("./static/")))
+
+	cfg := terminal.ListenConfig{Addr: ":8080"}
+	ln, addr, err := terminal.Listen(cfg)
+	if err != nil {
+		log.Fatal("Server failed to start:", err)
+	}
+
+	log.Printf("Server starting on %s", ln.Addr())
+	log.Printf("Terminal WebSocket endpoint: %s", addr.URL("/ws/terminal"))
 	log.Println("Static files served from ./static/")
-	
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+		err = http.ServeTLS(ln, mux, cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = http.Serve(ln, mux)
+	}
+	if err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
-}
\ No newline at end of file
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"log"
 	"net/http"
 
 	"github.com/standardbeagle/web-console/terminal"
@@ -11,6 +12,12 @@ type App struct {
 	ctx             context.Context
 	terminalHandler *terminal.Handler
 	server          *http.Server
+	listenAddr      terminal.ListenAddr
+
+	// ListenConfig is read once in OnStartup; set it before Wails calls
+	// OnStartup to bind a fixed port, TLS, or a Unix domain socket instead
+	// of the ":0"/plain-TCP default.
+	ListenConfig terminal.ListenConfig
 }
 
 func NewApp() *App {
@@ -21,18 +28,31 @@ func NewApp() *App {
 
 func (a *App) OnStartup(ctx context.Context) {
 	a.ctx = ctx
-	
+
 	mux := http.NewServeMux()
 	mux.Handle("/ws/terminal", a.terminalHandler)
-	
-	a.server = &http.Server{
-		Addr:    ":0", // Let system choose available port
-		Handler: mux,
+	mux.HandleFunc("/ws/terminal/join/", a.terminalHandler.ServeJoin)
+	mux.HandleFunc("/recordings/", a.terminalHandler.ServeRecording)
+	mux.HandleFunc("/replay/", a.terminalHandler.ServeReplay)
+
+	ln, addr, err := terminal.Listen(a.ListenConfig)
+	if err != nil {
+		log.Printf("Failed to start terminal server: %v", err)
+		return
 	}
-	
+	a.listenAddr = addr
+
+	a.server = &http.Server{Handler: mux}
+
 	go func() {
-		if err := a.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			// Log error but don't crash the app
+		var err error
+		if a.ListenConfig.TLSCertFile != "" && a.ListenConfig.TLSKeyFile != "" {
+			err = a.server.ServeTLS(ln, a.ListenConfig.TLSCertFile, a.ListenConfig.TLSKeyFile)
+		} else {
+			err = a.server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("Terminal server stopped: %v", err)
 		}
 	}()
 }
@@ -47,13 +67,16 @@ func (a *App) GetTerminalURL() string {
 	if a.server == nil {
 		return ""
 	}
-	
-	addr := a.server.Addr
-	if addr == ":0" {
-		// In production, you'd need to get the actual assigned port
-		// For now, return a placeholder
-		return "ws://localhost:8080/ws/terminal"
+	return a.listenAddr.URL("/ws/terminal")
+}
+
+// GetShareURL returns a join URL for an existing, already-created session
+// so the host can hand it out as a read-only (or later promoted) spectator
+// link.
+func (a *App) GetShareURL(sessionID string) string {
+	base := a.GetTerminalURL()
+	if base == "" {
+		return ""
 	}
-	
-	return "ws://localhost" + addr + "/ws/terminal"
-}
\ No newline at end of file
+	return base + "/join/" + sessionID
+}
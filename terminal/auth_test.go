@@ -0,0 +1,108 @@
+package terminal
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHMACAuthenticatorBearerToken(t *testing.T) {
+	auth := &HMACAuthenticator{Tokens: map[string]*Principal{
+		"good-token": {ID: "alice", Role: "host"},
+	}}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer good-token")
+
+	principal, err := auth.Authenticate(r)
+	if err != nil {
+		t.Fatalf("expected valid bearer token to authenticate, got: %v", err)
+	}
+	if principal.ID != "alice" {
+		t.Fatalf("expected principal alice, got %q", principal.ID)
+	}
+
+	r.Header.Set("Authorization", "Bearer wrong-token")
+	if _, err := auth.Authenticate(r); err == nil {
+		t.Fatalf("expected an unknown bearer token to be rejected")
+	}
+}
+
+func TestHMACAuthenticatorQueryToken(t *testing.T) {
+	auth := &HMACAuthenticator{Secret: []byte("shared-secret")}
+
+	token := auth.SignQueryToken("sess1", "viewer", time.Now().Add(time.Minute))
+	r, _ := http.NewRequest(http.MethodGet, "/?token="+token, nil)
+
+	principal, err := auth.Authenticate(r)
+	if err != nil {
+		t.Fatalf("expected a freshly signed query token to authenticate, got: %v", err)
+	}
+	if principal.ID != "sess1" || principal.Role != "viewer" {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+
+	expired := auth.SignQueryToken("sess1", "viewer", time.Now().Add(-time.Minute))
+	r2, _ := http.NewRequest(http.MethodGet, "/?token="+expired, nil)
+	if _, err := auth.Authenticate(r2); err == nil {
+		t.Fatalf("expected an expired query token to be rejected")
+	}
+
+	last := "0"
+	if token[len(token)-1] == '0' {
+		last = "1"
+	}
+	tampered := token[:len(token)-1] + last
+	r3, _ := http.NewRequest(http.MethodGet, "/?token="+tampered, nil)
+	if _, err := auth.Authenticate(r3); err == nil {
+		t.Fatalf("expected a tampered query token signature to be rejected")
+	}
+}
+
+func TestAuthConfigOriginAllowlist(t *testing.T) {
+	cfg := &AuthConfig{OriginAllowlist: []string{"https://app.example.com", "*.trusted.example.com"}}
+
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"https://sub.trusted.example.com", true},
+		{"https://evil.example.com", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		if got := cfg.originAllowed(c.origin); got != c.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", c.origin, got, c.want)
+		}
+	}
+}
+
+func TestCheckAuthRejectsDisallowedOrigin(t *testing.T) {
+	h := NewHandler().WithAuth(AuthConfig{
+		OriginAllowlist: []string{"https://app.example.com"},
+		Authenticator:   &HMACAuthenticator{Tokens: map[string]*Principal{}},
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+
+	if _, err := h.checkAuth(r); err == nil {
+		t.Fatalf("expected a request from a disallowed origin to be rejected before authentication")
+	}
+}
+
+func TestPrincipalContextRoundTrip(t *testing.T) {
+	want := &Principal{ID: "alice", Role: "host"}
+	ctx := contextWithPrincipal(context.Background(), want)
+
+	if got := principalFromContext(ctx); got != want {
+		t.Fatalf("expected %+v back from context, got %+v", want, got)
+	}
+
+	if got := principalFromContext(context.Background()); got != nil {
+		t.Fatalf("expected a context with no principal to yield nil, got %+v", got)
+	}
+}
@@ -0,0 +1,245 @@
+package terminal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// TransportConfig controls the host WebSocket's output buffering and
+// permessage-deflate compression.
+type TransportConfig struct {
+	// OutputBufferBytes bounds the host's output ring buffer; zero means
+	// defaultOutputBufferBytes.
+	OutputBufferBytes int
+
+	// EnableCompression negotiates permessage-deflate with clients that
+	// request it. Compression stays off unless negotiated, even then.
+	EnableCompression bool
+	// CompressionLevel is passed to the connection when compression was
+	// negotiated; zero uses gorilla's default.
+	CompressionLevel int
+}
+
+// syncPoolBufferPool adapts a sync.Pool to gorilla's websocket.BufferPool,
+// used as the Upgrader's WriteBufferPool so per-connection write buffers
+// are reused instead of allocated fresh on every upgrade.
+type syncPoolBufferPool struct {
+	pool sync.Pool
+}
+
+func newSyncPoolBufferPool() *syncPoolBufferPool {
+	return &syncPoolBufferPool{
+		pool: sync.Pool{New: func() interface{} { return make([]byte, 0, 4096) }},
+	}
+}
+
+func (p *syncPoolBufferPool) Get() interface{}  { return p.pool.Get() }
+func (p *syncPoolBufferPool) Put(v interface{}) { p.pool.Put(v) }
+
+// applyCompression turns on write-side compression for conn only when the
+// client actually negotiated permessage-deflate and the handler has
+// compression enabled; otherwise binary frames are written uncompressed.
+func (h *Handler) applyCompression(conn *websocket.Conn, r *http.Request) {
+	if !h.transport.EnableCompression {
+		return
+	}
+	if !strings.Contains(r.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		return
+	}
+
+	conn.EnableWriteCompression(true)
+	if h.transport.CompressionLevel != 0 {
+		conn.SetCompressionLevel(h.transport.CompressionLevel)
+	}
+}
+
+// defaultOutputBufferBytes bounds how much PTY output can sit between the
+// read loop and the host's writer goroutine before it's coalesced, and
+// eventually dropped, rather than blocking the PTY.
+const defaultOutputBufferBytes = 1 << 20 // 1 MiB
+
+// Metrics receives flow-control counters so operators can alert on a
+// session that's falling behind. All methods must be safe for concurrent
+// use; implementations that don't care about a given signal can no-op it.
+type Metrics interface {
+	BytesIn(n int)
+	BytesOut(n int)
+	BytesDropped(n int)
+	QueueDepth(n int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) BytesIn(int)      {}
+func (noopMetrics) BytesOut(int)     {}
+func (noopMetrics) BytesDropped(int) {}
+func (noopMetrics) QueueDepth(int)   {}
+
+// CounterMetrics is a ready-to-use Metrics implementation that just keeps
+// running totals, for embedders who want numbers without wiring up their
+// own collector.
+type CounterMetrics struct {
+	mu                                     sync.Mutex
+	bytesIn, bytesOut, bytesDropped, depth int64
+}
+
+func NewCounterMetrics() *CounterMetrics {
+	return &CounterMetrics{}
+}
+
+func (m *CounterMetrics) BytesIn(n int) {
+	m.mu.Lock()
+	m.bytesIn += int64(n)
+	m.mu.Unlock()
+}
+
+func (m *CounterMetrics) BytesOut(n int) {
+	m.mu.Lock()
+	m.bytesOut += int64(n)
+	m.mu.Unlock()
+}
+
+func (m *CounterMetrics) BytesDropped(n int) {
+	m.mu.Lock()
+	m.bytesDropped += int64(n)
+	m.mu.Unlock()
+}
+
+func (m *CounterMetrics) QueueDepth(n int) {
+	m.mu.Lock()
+	m.depth = int64(n)
+	m.mu.Unlock()
+}
+
+// Snapshot returns the current totals.
+func (m *CounterMetrics) Snapshot() (bytesIn, bytesOut, bytesDropped, queueDepth int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytesIn, m.bytesOut, m.bytesDropped, m.depth
+}
+
+// maxCoalescedChunkBytes bounds how large a single coalesced chunk in the
+// ring is allowed to grow. It matches Message.Marshal's uint16 data-length
+// limit so a chunk handed to sendMessage can always be wire-encoded, and it
+// keeps chunks genuinely separate so the capacity eviction below can still
+// drop the oldest ones instead of growing one buffer without bound.
+const maxCoalescedChunkBytes = 0xFFFF
+
+// outputRing is a byte-budgeted queue of PTY output chunks sitting between
+// the PTY read loop and the host's writer goroutine. Adjacent pushes are
+// coalesced into the last chunk up to maxCoalescedChunkBytes; once the
+// budget is exceeded, the oldest chunks are dropped (and counted) so a
+// stalled host connection can never block the PTY reader.
+type outputRing struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	chunks   [][]byte
+	dropped  int
+	notify   chan struct{}
+}
+
+func newOutputRing(capacity int) *outputRing {
+	if capacity <= 0 {
+		capacity = defaultOutputBufferBytes
+	}
+	return &outputRing{capacity: capacity, notify: make(chan struct{}, 1)}
+}
+
+func (r *outputRing) push(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Cap a coalesced chunk at the ring's own capacity too, not just the
+	// wire limit: otherwise a capacity much smaller than
+	// maxCoalescedChunkBytes would keep growing a single chunk forever and
+	// the eviction loop below, which refuses to drop the last chunk, would
+	// never get a second one to drop.
+	maxChunk := maxCoalescedChunkBytes
+	if r.capacity > 0 && r.capacity < maxChunk {
+		maxChunk = r.capacity
+	}
+
+	for len(data) > 0 {
+		if n := len(r.chunks); n > 0 {
+			if room := maxChunk - len(r.chunks[n-1]); room > 0 {
+				take := room
+				if take > len(data) {
+					take = len(data)
+				}
+				r.chunks[n-1] = append(r.chunks[n-1], data[:take]...)
+				r.size += take
+				data = data[take:]
+				continue
+			}
+		}
+
+		take := len(data)
+		if take > maxChunk {
+			take = maxChunk
+		}
+		r.chunks = append(r.chunks, append([]byte(nil), data[:take]...))
+		r.size += take
+		data = data[take:]
+	}
+
+	for r.size > r.capacity && len(r.chunks) > 1 {
+		dropped := r.chunks[0]
+		r.chunks = r.chunks[1:]
+		r.size -= len(dropped)
+		r.dropped += len(dropped)
+	}
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain removes and returns everything currently queued, along with the
+// number of bytes dropped since the last drain.
+func (r *outputRing) drain() ([][]byte, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chunks := r.chunks
+	dropped := r.dropped
+	r.chunks = nil
+	r.size = 0
+	r.dropped = 0
+	return chunks, dropped
+}
+
+func (r *outputRing) depth() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size
+}
+
+// writerLoop drains the output ring into the host WebSocket connection,
+// flushing a MsgTypeTruncated marker whenever the ring had to drop output.
+func (s *Session) writerLoop() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.outputBuf.notify:
+			chunks, dropped := s.outputBuf.drain()
+
+			if dropped > 0 {
+				s.metrics.BytesDropped(dropped)
+				s.sendMessage(&Message{Type: MsgTypeTruncated, Data: []byte(fmt.Sprintf("%d", dropped))})
+			}
+
+			for _, chunk := range chunks {
+				s.sendMessage(&Message{Type: MsgTypeData, Data: chunk})
+			}
+
+			s.metrics.QueueDepth(s.outputBuf.depth())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package terminal
+
+import "testing"
+
+func TestOutputRingDropsOldestOverCapacity(t *testing.T) {
+	r := newOutputRing(10)
+
+	r.push(make([]byte, 10))
+	r.push(make([]byte, 10))
+	r.push(make([]byte, 10))
+
+	chunks, dropped := r.drain()
+	if dropped == 0 {
+		t.Fatalf("expected some bytes dropped once over capacity, got 0")
+	}
+	if got := len(chunks); got != 1 {
+		t.Fatalf("expected the newest chunk to survive, got %d chunks", got)
+	}
+	if r.size != 0 {
+		t.Fatalf("expected drain to reset size, got %d", r.size)
+	}
+}
+
+func TestOutputRingCapsCoalescedChunkAtWireLimit(t *testing.T) {
+	r := newOutputRing(1 << 20)
+
+	r.push(make([]byte, maxCoalescedChunkBytes))
+	r.push(make([]byte, 100))
+
+	for _, chunk := range r.chunks {
+		if len(chunk) > maxCoalescedChunkBytes {
+			t.Fatalf("chunk of %d bytes exceeds wire limit %d", len(chunk), maxCoalescedChunkBytes)
+		}
+	}
+	if len(r.chunks) != 2 {
+		t.Fatalf("expected a new chunk once the first hit the wire limit, got %d chunks", len(r.chunks))
+	}
+}
@@ -12,6 +12,9 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// CheckOrigin is intentionally permissive here: origin pinning is enforced
+// earlier, in Handler.checkAuth, where it can be configured per-Handler via
+// WithAuth instead of hardcoded for every handler that shares this Upgrader.
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
@@ -20,63 +23,208 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 4096,
 }
 
+// hostWriterID is the sentinel writer ID representing the session's
+// original host connection, as opposed to a viewer ID handed out on join.
+const hostWriterID = "host"
+
 type Session struct {
+	id        string
 	conn      *websocket.Conn
-	pty       *PTY
+	ptyMu     sync.Mutex
+	pty       PTYSession
 	ctx       context.Context
 	cancel    context.CancelFunc
 	writeMu   sync.Mutex
 	closeOnce sync.Once
+
+	hostSizeMu         sync.RWMutex
+	hostCols, hostRows uint16
+
+	roleMu   sync.RWMutex
+	writerID string
+
+	viewersMu sync.RWMutex
+	viewers   map[string]*viewer
+
+	store SessionStore
+
+	recordingCfg *RecordingConfig
+	recording    *recording
+
+	ptyProvider PTYProvider
+	profile     *ShellProfile
+
+	lastInputMu sync.RWMutex
+	lastInput   time.Time
+
+	principal   *Principal
+	rateLimiter RateLimiter
+
+	outputBuf *outputRing
+	metrics   Metrics
 }
 
 type Handler struct {
-	sessions sync.Map
-	mu       sync.RWMutex
+	sessions  sync.Map
+	mu        sync.RWMutex
+	store     SessionStore
+	recording RecordingConfig
+
+	ptyProvider     PTYProvider
+	profileResolver ProfileResolver
+	profiles        map[string]*ShellProfile
+
+	authCfg *AuthConfig
+
+	transport  TransportConfig
+	metrics    Metrics
+	wsUpgrader websocket.Upgrader
 }
 
 func NewHandler() *Handler {
-	return &Handler{}
+	h := &Handler{
+		store:       NewInMemorySessionStore(),
+		ptyProvider: NewDefaultPTYProvider(),
+		transport:   TransportConfig{OutputBufferBytes: defaultOutputBufferBytes},
+	}
+	h.wsUpgrader = upgrader
+	h.wsUpgrader.WriteBufferPool = newSyncPoolBufferPool()
+	return h
+}
+
+// WithTransport configures the host WebSocket's output buffering and
+// permessage-deflate compression. Compression stays off for binary frames
+// unless the client actually negotiates permessage-deflate.
+func (h *Handler) WithTransport(cfg TransportConfig) *Handler {
+	h.transport = cfg
+	h.wsUpgrader.EnableCompression = cfg.EnableCompression
+	return h
+}
+
+// WithMetrics reports flow-control counters (bytes in/out/dropped, queue
+// depth) for every session created by this handler from now on.
+func (h *Handler) WithMetrics(m Metrics) *Handler {
+	h.metrics = m
+	return h
+}
+
+// WithRecording enables asciicast recording for every session created by
+// this handler from now on.
+func (h *Handler) WithRecording(cfg RecordingConfig) *Handler {
+	h.recording = cfg
+	return h
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	principal, err := h.checkAuth(r)
+	if err != nil {
+		log.Printf("AUDIT: refusing connection from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.authCfg != nil && h.authCfg.RateLimiter != nil && !h.authCfg.RateLimiter.AllowSession(principalID(principal)) {
+		http.Error(w, "too many sessions", http.StatusTooManyRequests)
+		return
+	}
+
+	profile, err := h.resolveProfile(r, principal)
+	if err != nil {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	conn, err := h.wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
 	}
+	h.applyCompression(conn, r)
 
-	session := h.createSession(conn)
+	session, err := h.createSession(conn, profile, principal)
+	if err != nil {
+		log.Printf("Failed to create session: %v", err)
+		conn.Close()
+		return
+	}
 	h.sessions.Store(session, struct{}{})
-	
+
 	go session.handleConnection()
 }
 
-func (h *Handler) createSession(conn *websocket.Conn) *Session {
+func principalID(p *Principal) string {
+	if p == nil {
+		return "anonymous"
+	}
+	return p.ID
+}
+
+func (h *Handler) createSession(conn *websocket.Conn, profile *ShellProfile, principal *Principal) (*Session, error) {
 	ctx, cancel := context.WithCancel(context.Background())
-	
-	return &Session{
-		conn:   conn,
-		ctx:    ctx,
-		cancel: cancel,
+
+	id, err := newSessionID()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var rateLimiter RateLimiter
+	if h.authCfg != nil {
+		rateLimiter = h.authCfg.RateLimiter
 	}
+
+	metrics := h.metrics
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+
+	session := &Session{
+		id:           id,
+		conn:         conn,
+		ctx:          ctx,
+		cancel:       cancel,
+		writerID:     hostWriterID,
+		viewers:      make(map[string]*viewer),
+		store:        h.store,
+		recordingCfg: &h.recording,
+		ptyProvider:  h.ptyProvider,
+		profile:      profile,
+		principal:    principal,
+		rateLimiter:  rateLimiter,
+		outputBuf:    newOutputRing(h.transport.OutputBufferBytes),
+		metrics:      metrics,
+	}
+
+	if err := h.store.CreateSession(id, session); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// ID returns the session's share ID, used by callers to build a join link
+// (e.g. App.GetShareURL) once the session has been created.
+func (s *Session) ID() string {
+	return s.id
 }
 
 func (s *Session) handleConnection() {
 	defer s.cleanup()
-	
+
 	conn := s.conn
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 		return nil
 	})
-	
+
 	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	
+
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
-	
+
 	go s.heartbeat(ticker)
-	
+
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -89,7 +237,7 @@ func (s *Session) handleConnection() {
 				}
 				return
 			}
-			
+
 			if err := s.handleMessage(data); err != nil {
 				log.Printf("Message handling error: %v", err)
 				s.sendError(fmt.Sprintf("Error: %v", err))
@@ -103,14 +251,24 @@ func (s *Session) handleMessage(data []byte) error {
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal message: %w", err)
 	}
-	
+
 	switch msg.Type {
 	case MsgTypeData:
+		if !s.isWriter(hostWriterID) {
+			return nil
+		}
 		return s.handleData(msg.Data)
 	case MsgTypeResize:
-		return s.handleResize(msg.Data)
+		return s.handleHostResize(msg.Data)
 	case MsgTypeControl:
+		if !s.isWriter(hostWriterID) {
+			return nil
+		}
 		return s.handleControl(msg.Data)
+	case MsgTypeRoleGrant:
+		return s.handleRoleChange(hostWriterID, string(msg.Data))
+	case MsgTypeRoleRevoke:
+		return s.handleRoleChange(hostWriterID, hostWriterID)
 	case MsgTypeHeartbeat:
 		return nil
 	case MsgTypeClose:
@@ -121,47 +279,126 @@ func (s *Session) handleMessage(data []byte) error {
 	}
 }
 
-func (s *Session) handleData(data []byte) error {
-	if s.pty == nil {
-		pty, err := NewPTY(80, 24)
-		if err != nil {
-			return fmt.Errorf("failed to create PTY: %w", err)
+// ensurePTY lazily spawns the session's PTY at the given size the first
+// time it's needed, starting the recorder, the idle watchdog, and the
+// read loop alongside it. The check-and-create is locked because the host
+// (handleHostResize) and the current writer (handleData) can both race to
+// call it on their own goroutines.
+func (s *Session) ensurePTY(cols, rows uint16) error {
+	s.ptyMu.Lock()
+	defer s.ptyMu.Unlock()
+
+	if s.pty != nil {
+		return nil
+	}
+
+	ctx := contextWithPrincipal(s.ctx, s.principal)
+	if s.profile != nil {
+		ctx = contextWithProfile(ctx, s.profile)
+	}
+
+	ptyProvider := s.ptyProvider
+	if ptyProvider == nil {
+		ptyProvider = NewDefaultPTYProvider()
+	}
+
+	ptySession, err := ptyProvider.CreatePTY(ctx, cols, rows)
+	if err != nil {
+		return fmt.Errorf("failed to create PTY: %w", err)
+	}
+	s.pty = ptySession
+	s.startRecording(cols, rows)
+	s.touchInput()
+
+	if s.profile != nil && s.profile.IdleTimeout > 0 {
+		go s.watchIdle(s.profile.IdleTimeout)
+	}
+
+	go s.writerLoop()
+	go s.readFromPTY()
+	return nil
+}
+
+func (s *Session) touchInput() {
+	s.lastInputMu.Lock()
+	s.lastInput = time.Now()
+	s.lastInputMu.Unlock()
+}
+
+// watchIdle kills the PTY once timeout has elapsed since the last input,
+// enforcing the profile's IdleTimeout.
+func (s *Session) watchIdle(timeout time.Duration) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.lastInputMu.RLock()
+			idle := time.Since(s.lastInput)
+			s.lastInputMu.RUnlock()
+
+			if idle >= timeout {
+				log.Printf("AUDIT: killing idle PTY for session %s after %s", s.id, idle)
+				if s.pty != nil {
+					s.pty.Close()
+				}
+				s.cancel()
+				return
+			}
 		}
-		s.pty = pty
-		go s.readFromPTY()
 	}
-	
+}
+
+func (s *Session) handleData(data []byte) error {
+	if err := s.ensurePTY(80, 24); err != nil {
+		return err
+	}
+
+	s.touchInput()
+
+	if s.recording != nil {
+		s.recording.input(data)
+	}
+
 	_, err := s.pty.Write(data)
 	return err
 }
 
-func (s *Session) handleResize(data []byte) error {
-	if s.pty == nil {
-		pty, err := NewPTY(80, 24)
-		if err != nil {
-			return fmt.Errorf("failed to create PTY: %w", err)
-		}
-		s.pty = pty
-		go s.readFromPTY()
-	}
-	
+// handleHostResize applies the host's own window size, then asks the
+// session to recompute the PTY size from the host plus every viewer.
+func (s *Session) handleHostResize(data []byte) error {
 	resizeData, err := UnmarshalResizeData(data)
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal resize data: %w", err)
 	}
-	
-	return s.pty.Resize(resizeData.Cols, resizeData.Rows)
+
+	s.hostSizeMu.Lock()
+	s.hostCols, s.hostRows = resizeData.Cols, resizeData.Rows
+	s.hostSizeMu.Unlock()
+
+	if err := s.ensurePTY(resizeData.Cols, resizeData.Rows); err != nil {
+		return err
+	}
+
+	if s.recording != nil {
+		s.recording.resize(resizeData.Cols, resizeData.Rows)
+	}
+
+	return s.recomputeSize()
 }
 
 func (s *Session) handleControl(data []byte) error {
 	if s.pty == nil {
 		return fmt.Errorf("no PTY available")
 	}
-	
+
 	if len(data) < 1 {
 		return fmt.Errorf("invalid control data")
 	}
-	
+
 	signal := syscall.Signal(data[0])
 	return s.pty.SendSignal(signal)
 }
@@ -170,9 +407,9 @@ func (s *Session) readFromPTY() {
 	if s.pty == nil {
 		return
 	}
-	
+
 	buffer := make([]byte, 4096)
-	
+
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -185,22 +422,26 @@ func (s *Session) readFromPTY() {
 				}
 				return
 			}
-			
+
 			if n > 0 {
-				s.sendData(buffer[:n])
+				chunk := append([]byte(nil), buffer[:n]...)
+
+				s.metrics.BytesIn(len(chunk))
+				if s.recording != nil {
+					s.recording.output(chunk)
+				}
+				s.broadcastToViewers(&Message{Type: MsgTypeData, Data: chunk})
+
+				// Queue for the host's writer goroutine instead of writing
+				// here directly, so a stalled host connection can never
+				// block the PTY reader.
+				s.outputBuf.push(chunk)
+				s.metrics.QueueDepth(s.outputBuf.depth())
 			}
 		}
 	}
 }
 
-func (s *Session) sendData(data []byte) {
-	msg := &Message{
-		Type: MsgTypeData,
-		Data: data,
-	}
-	s.sendMessage(msg)
-}
-
 func (s *Session) sendError(errMsg string) {
 	msg := &Message{
 		Type: MsgTypeError,
@@ -215,15 +456,21 @@ func (s *Session) sendMessage(msg *Message) {
 		log.Printf("Failed to marshal message: %v", err)
 		return
 	}
-	
+
+	if s.rateLimiter != nil && !s.rateLimiter.AllowBytes(principalID(s.principal), len(data)) {
+		return
+	}
+
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
-	
+
 	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 	if err := s.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
 		log.Printf("Failed to write message: %v", err)
 		s.cancel()
+		return
 	}
+	s.metrics.BytesOut(len(data))
 }
 
 func (s *Session) heartbeat(ticker *time.Ticker) {
@@ -247,11 +494,17 @@ func (s *Session) heartbeat(ticker *time.Ticker) {
 func (s *Session) cleanup() {
 	s.closeOnce.Do(func() {
 		s.cancel()
-		
+
 		if s.pty != nil {
 			s.pty.Close()
 		}
-		
+
+		s.closeViewers()
+		s.stopRecording()
 		s.conn.Close()
+
+		if s.store != nil {
+			s.store.DeleteSession(s.id)
+		}
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,93 @@
+package terminal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+// fakePTYSession is a no-op PTYSession for tests that only need to exercise
+// Session's own synchronization, not a real PTY.
+type fakePTYSession struct{}
+
+func (fakePTYSession) Read([]byte) (int, error)        { select {} }
+func (fakePTYSession) Write(data []byte) (int, error)  { return len(data), nil }
+func (fakePTYSession) Resize(uint16, uint16) error     { return nil }
+func (fakePTYSession) SendSignal(syscall.Signal) error { return nil }
+func (fakePTYSession) Wait() error                     { return nil }
+func (fakePTYSession) Close() error                    { return nil }
+
+// countingPTYProvider counts how many times CreatePTY is actually invoked,
+// so a test can assert ensurePTY's lazy spawn never races into creating two.
+type countingPTYProvider struct {
+	calls int32
+}
+
+func (p *countingPTYProvider) CreatePTY(ctx context.Context, cols, rows uint16) (PTYSession, error) {
+	atomic.AddInt32(&p.calls, 1)
+	return fakePTYSession{}, nil
+}
+
+func newTestSession(provider PTYProvider) *Session {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Session{
+		ctx:         ctx,
+		cancel:      cancel,
+		writerID:    hostWriterID,
+		viewers:     make(map[string]*viewer),
+		ptyProvider: provider,
+		metrics:     noopMetrics{},
+		outputBuf:   newOutputRing(0),
+	}
+}
+
+func TestEnsurePTYConcurrentCallsSpawnOnlyOnePTY(t *testing.T) {
+	provider := &countingPTYProvider{}
+	s := newTestSession(provider)
+	defer s.cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.ensurePTY(80, 24); err != nil {
+				t.Errorf("ensurePTY failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&provider.calls); got != 1 {
+		t.Fatalf("expected exactly one PTY to be spawned under concurrent ensurePTY calls, got %d", got)
+	}
+}
+
+func TestHostSizeAndRecomputeSizeConcurrentAccess(t *testing.T) {
+	s := newTestSession(&countingPTYProvider{})
+	defer s.cancel()
+	s.pty = fakePTYSession{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			s.hostSizeMu.Lock()
+			s.hostCols, s.hostRows = uint16(n), uint16(n)
+			s.hostSizeMu.Unlock()
+		}(i + 1)
+	}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.recomputeSize(); err != nil {
+				t.Errorf("recomputeSize failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -3,9 +3,11 @@ package terminal
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -23,6 +25,10 @@ func NewDefaultPTYProvider() *DefaultPTYProvider {
 }
 
 func (p *DefaultPTYProvider) CreatePTY(ctx context.Context, cols, rows uint16) (PTYSession, error) {
+	if profile, ok := profileFromContext(ctx); ok && profile != nil {
+		return p.createFromProfile(ctx, profile, cols, rows)
+	}
+
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
 		cmd = exec.CommandContext(ctx, "cmd.exe")
@@ -33,9 +39,9 @@ func (p *DefaultPTYProvider) CreatePTY(ctx context.Context, cols, rows uint16) (
 		}
 		cmd = exec.CommandContext(ctx, shell)
 	}
-	
+
 	cmd.Env = os.Environ()
-	
+
 	ptyFile, err := pty.StartWithSize(cmd, &pty.Winsize{
 		Rows: rows,
 		Cols: cols,
@@ -43,13 +49,116 @@ func (p *DefaultPTYProvider) CreatePTY(ctx context.Context, cols, rows uint16) (
 	if err != nil {
 		return nil, err
 	}
-	
+
+	return &DefaultPTYSession{
+		cmd: cmd,
+		pty: ptyFile,
+	}, nil
+}
+
+// createFromProfile spawns a sandboxed PTY constrained by profile instead
+// of the legacy $SHELL-with-full-env path above.
+func (p *DefaultPTYProvider) createFromProfile(ctx context.Context, profile *ShellProfile, cols, rows uint16) (PTYSession, error) {
+	pid := principalID(principalFromContext(ctx))
+
+	if profile.Command == "" {
+		log.Printf("AUDIT: refusing to spawn PTY for principal %s, shell profile %q has no command configured", pid, profile.Name)
+		return nil, fmt.Errorf("shell profile %q has no command configured", profile.Name)
+	}
+
+	name, args := withRLimits(profile, profile.Command, profile.Args)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = filterEnv(os.Environ(), profile.EnvAllowlist, profile.EnvDenylist)
+
+	if profile.Dir != "" {
+		cmd.Dir = profile.Dir
+	}
+
+	if runtime.GOOS != "windows" && (profile.UID != nil || profile.GID != nil) {
+		cred := &syscall.Credential{}
+		if profile.UID != nil {
+			cred.Uid = *profile.UID
+		}
+		if profile.GID != nil {
+			cred.Gid = *profile.GID
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+	}
+
+	ptyFile, err := pty.StartWithSize(cmd, &pty.Winsize{
+		Rows: rows,
+		Cols: cols,
+	})
+	if err != nil {
+		log.Printf("AUDIT: failed to spawn PTY for principal %s, shell profile %q: %v", pid, profile.Name, err)
+		return nil, fmt.Errorf("failed to start shell profile %q: %w", profile.Name, err)
+	}
+
+	log.Printf("AUDIT: spawned PTY for principal %s, shell profile %q (%s)", pid, profile.Name, profile.Command)
+
 	return &DefaultPTYSession{
 		cmd: cmd,
 		pty: ptyFile,
 	}, nil
 }
 
+// filterEnv applies an allowlist/denylist on top of base (typically
+// os.Environ()). An empty allowlist means "allow everything not denied".
+func filterEnv(base, allowlist, denylist []string) []string {
+	if len(allowlist) == 0 && len(denylist) == 0 {
+		return base
+	}
+
+	allow := make(map[string]bool, len(allowlist))
+	for _, k := range allowlist {
+		allow[k] = true
+	}
+	deny := make(map[string]bool, len(denylist))
+	for _, k := range denylist {
+		deny[k] = true
+	}
+
+	var filtered []string
+	for _, kv := range base {
+		key := strings.SplitN(kv, "=", 2)[0]
+		if deny[key] {
+			continue
+		}
+		if len(allow) > 0 && !allow[key] {
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	return filtered
+}
+
+// withRLimits wraps the command in a prlimit invocation when the profile
+// requests CPU, address-space, or file-descriptor limits. Limits are only
+// supported on non-Windows platforms where prlimit(1) is available.
+func withRLimits(profile *ShellProfile, name string, args []string) (string, []string) {
+	if runtime.GOOS == "windows" {
+		return name, args
+	}
+
+	var limits []string
+	if profile.RLimitCPU > 0 {
+		limits = append(limits, fmt.Sprintf("--cpu=%d", profile.RLimitCPU))
+	}
+	if profile.RLimitAS > 0 {
+		limits = append(limits, fmt.Sprintf("--as=%d", profile.RLimitAS))
+	}
+	if profile.RLimitNOFILE > 0 {
+		limits = append(limits, fmt.Sprintf("--nofile=%d", profile.RLimitNOFILE))
+	}
+	if len(limits) == 0 {
+		return name, args
+	}
+
+	wrapped := append(limits, "--", name)
+	wrapped = append(wrapped, args...)
+	return "prlimit", wrapped
+}
+
 // DefaultPTYSession implements PTYSession
 type DefaultPTYSession struct {
 	cmd    *exec.Cmd
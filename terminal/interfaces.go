@@ -55,6 +55,25 @@ type SessionStore interface {
 	ListSessions() []*Session
 }
 
+// RecordingStore persists and serves back asciicast recordings keyed by
+// session ID, so recordings can live on disk, in S3, or anywhere else a
+// ReadSeekCloser/WriteCloser pair can be produced.
+type RecordingStore interface {
+	Create(sessionID string) (io.WriteCloser, error)
+	Open(sessionID string) (io.ReadSeekCloser, error)
+	Delete(sessionID string) error
+	List() ([]string, error)
+}
+
+// RecordingConfig controls whether and how sessions are recorded to
+// asciicast v2 files. Rotation and retention limits are a property of the
+// chosen Store (e.g. DiskRecordingStore), not of this config.
+type RecordingConfig struct {
+	Enabled      bool
+	CaptureInput bool
+	Store        RecordingStore
+}
+
 // Configuration for dependency injection
 type Config struct {
 	PTYProvider       PTYProvider
@@ -62,4 +81,5 @@ type Config struct {
 	ConnectionManager ConnectionManager
 	EventBus          EventBus
 	SessionStore      SessionStore
+	Recording         RecordingConfig
 }
\ No newline at end of file
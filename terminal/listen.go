@@ -0,0 +1,80 @@
+package terminal
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ListenConfig describes how a Handler's HTTP server should bind, so that
+// the resulting WebSocket URL (scheme, host, and path) can be derived from
+// the listener actually obtained rather than hardcoded by the caller.
+type ListenConfig struct {
+	// Addr is the TCP address to listen on, e.g. ":8080" or ":0" to let
+	// the OS pick a free port. Ignored when UnixSocket is set.
+	Addr string
+
+	// UnixSocket, if set, binds a Unix domain socket at this path instead
+	// of a TCP address.
+	UnixSocket string
+
+	// TLSCertFile and TLSKeyFile, if both set, mean the server will be
+	// served over TLS, so ListenAddr reports a wss:// (or wss+unix://)
+	// scheme instead of ws://.
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+// ListenAddr is the origin clients should use to build WebSocket URLs
+// against a listener obtained via Listen.
+type ListenAddr struct {
+	scheme string
+	host   string
+	unix   bool
+}
+
+// URL joins path (e.g. "/ws/terminal") onto the listener's origin. Unix
+// domain sockets have no host:port to hang a path off of, so they use the
+// "scheme+unix://socket/path:route" convention instead, e.g.
+// "ws+unix:///tmp/app.sock:/ws/terminal".
+func (a ListenAddr) URL(path string) string {
+	if a.unix {
+		return fmt.Sprintf("%s://%s:%s", a.scheme, a.host, path)
+	}
+	return fmt.Sprintf("%s://%s%s", a.scheme, a.host, path)
+}
+
+// Listen binds cfg's address, TCP or Unix domain socket, and returns the
+// resulting net.Listener along with the origin clients should use to reach
+// it. Binding to ":0" (the default) lets the OS assign a free port, which
+// the returned ListenAddr reflects.
+func Listen(cfg ListenConfig) (net.Listener, ListenAddr, error) {
+	scheme := "ws"
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+		scheme = "wss"
+	}
+
+	if cfg.UnixSocket != "" {
+		ln, err := net.Listen("unix", cfg.UnixSocket)
+		if err != nil {
+			return nil, ListenAddr{}, fmt.Errorf("failed to listen on unix socket %s: %w", cfg.UnixSocket, err)
+		}
+		return ln, ListenAddr{scheme: scheme + "+unix", host: cfg.UnixSocket, unix: true}, nil
+	}
+
+	addr := cfg.Addr
+	if addr == "" {
+		addr = ":0"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, ListenAddr{}, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	tcpAddr := ln.Addr().(*net.TCPAddr)
+	host := tcpAddr.IP.String()
+	if tcpAddr.IP.IsUnspecified() {
+		host = "localhost"
+	}
+	return ln, ListenAddr{scheme: scheme, host: net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))}, nil
+}
@@ -0,0 +1,16 @@
+package terminal
+
+import "testing"
+
+func TestListenBracketsIPv6Host(t *testing.T) {
+	ln, addr, err := Listen(ListenConfig{Addr: "[::1]:0"})
+	if err != nil {
+		t.Skipf("IPv6 loopback not available in this environment: %v", err)
+	}
+	defer ln.Close()
+
+	url := addr.URL("/ws/terminal")
+	if got, want := url[:len("ws://[::1]")], "ws://[::1]"; got != want {
+		t.Fatalf("expected bracketed IPv6 host, got %q", url)
+	}
+}
@@ -2,18 +2,23 @@ package terminal
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 )
 
 type MessageType uint8
 
 const (
-	MsgTypeData        MessageType = 0x01
-	MsgTypeResize      MessageType = 0x02
-	MsgTypeControl     MessageType = 0x03
-	MsgTypeError       MessageType = 0x04
-	MsgTypeHeartbeat   MessageType = 0x05
-	MsgTypeClose       MessageType = 0x06
+	MsgTypeData         MessageType = 0x01
+	MsgTypeResize       MessageType = 0x02
+	MsgTypeControl      MessageType = 0x03
+	MsgTypeError        MessageType = 0x04
+	MsgTypeHeartbeat    MessageType = 0x05
+	MsgTypeClose        MessageType = 0x06
+	MsgTypeParticipants MessageType = 0x07
+	MsgTypeRoleGrant    MessageType = 0x08
+	MsgTypeRoleRevoke   MessageType = 0x09
+	MsgTypeTruncated    MessageType = 0x0A
 )
 
 type Message struct {
@@ -72,9 +77,28 @@ func UnmarshalResizeData(data []byte) (*ResizeData, error) {
 	if len(data) < 4 {
 		return nil, errors.New("invalid resize data")
 	}
-	
+
 	return &ResizeData{
 		Cols: binary.LittleEndian.Uint16(data[0:2]),
 		Rows: binary.LittleEndian.Uint16(data[2:4]),
 	}, nil
+}
+
+// Participant describes one roster entry broadcast in a MsgTypeParticipants
+// frame: the connection's viewer ID (or "host") and its current role.
+type Participant struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+func MarshalParticipants(participants []Participant) ([]byte, error) {
+	return json.Marshal(participants)
+}
+
+func UnmarshalParticipants(data []byte) ([]Participant, error) {
+	var participants []Participant
+	if err := json.Unmarshal(data, &participants); err != nil {
+		return nil, err
+	}
+	return participants, nil
 }
\ No newline at end of file
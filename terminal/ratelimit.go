@@ -0,0 +1,81 @@
+package terminal
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a classic token bucket: it holds up to burst tokens,
+// refilled continuously at rate tokens/second.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu       sync.Mutex
+	tokens   float64
+	lastFill time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, lastFill: time.Now()}
+}
+
+func (b *tokenBucket) allow(n float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFill = now
+
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// TokenBucketRateLimiter implements RateLimiter with one session bucket
+// and one byte bucket per principal ID.
+type TokenBucketRateLimiter struct {
+	SessionRate, SessionBurst float64
+	ByteRate, ByteBurst       float64
+
+	mu             sync.Mutex
+	sessionBuckets map[string]*tokenBucket
+	byteBuckets    map[string]*tokenBucket
+}
+
+func NewTokenBucketRateLimiter(sessionRate, sessionBurst, byteRate, byteBurst float64) *TokenBucketRateLimiter {
+	return &TokenBucketRateLimiter{
+		SessionRate:    sessionRate,
+		SessionBurst:   sessionBurst,
+		ByteRate:       byteRate,
+		ByteBurst:      byteBurst,
+		sessionBuckets: make(map[string]*tokenBucket),
+		byteBuckets:    make(map[string]*tokenBucket),
+	}
+}
+
+func (l *TokenBucketRateLimiter) AllowSession(principalID string) bool {
+	return l.bucketFor(l.sessionBuckets, principalID, l.SessionRate, l.SessionBurst).allow(1)
+}
+
+func (l *TokenBucketRateLimiter) AllowBytes(principalID string, n int) bool {
+	return l.bucketFor(l.byteBuckets, principalID, l.ByteRate, l.ByteBurst).allow(float64(n))
+}
+
+func (l *TokenBucketRateLimiter) bucketFor(buckets map[string]*tokenBucket, principalID string, rate, burst float64) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := buckets[principalID]
+	if !ok {
+		b = newTokenBucket(rate, burst)
+		buckets[principalID] = b
+	}
+	return b
+}
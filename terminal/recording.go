@@ -0,0 +1,393 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// recordingHeader is the first line of an asciicast v2 file.
+type recordingHeader struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env"`
+}
+
+// recording writes a single session's PTY activity to an asciicast v2
+// stream: a header line followed by one [elapsed, kind, chunk] event per
+// line, in newline-delimited JSON.
+type recording struct {
+	mu           sync.Mutex
+	w            io.WriteCloser
+	start        time.Time
+	captureInput bool
+}
+
+func newRecording(w io.WriteCloser, cols, rows uint16, captureInput bool, principal *Principal) (*recording, error) {
+	env := map[string]string{
+		"SHELL": os.Getenv("SHELL"),
+		"TERM":  os.Getenv("TERM"),
+	}
+	if principal != nil {
+		env["PRINCIPAL"] = principal.ID
+	}
+
+	header := recordingHeader{
+		Version:   2,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: time.Now().Unix(),
+		Env:       env,
+	}
+
+	line, err := json.Marshal(header)
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("failed to marshal recording header: %w", err)
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+
+	return &recording{w: w, start: time.Now(), captureInput: captureInput}, nil
+}
+
+func (rec *recording) writeEvent(kind string, chunk []byte) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	elapsed := time.Since(rec.start).Seconds()
+	event := []interface{}{elapsed, kind, strings.ToValidUTF8(string(chunk), "�")}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recording event: %w", err)
+	}
+	_, err = rec.w.Write(append(line, '\n'))
+	return err
+}
+
+func (rec *recording) output(chunk []byte) {
+	if err := rec.writeEvent("o", chunk); err != nil {
+		log.Printf("Failed to write recording output event: %v", err)
+	}
+}
+
+func (rec *recording) input(chunk []byte) {
+	if !rec.captureInput {
+		return
+	}
+	if err := rec.writeEvent("i", chunk); err != nil {
+		log.Printf("Failed to write recording input event: %v", err)
+	}
+}
+
+func (rec *recording) resize(cols, rows uint16) {
+	if err := rec.writeEvent("r", []byte(fmt.Sprintf("%dx%d", cols, rows))); err != nil {
+		log.Printf("Failed to write recording resize event: %v", err)
+	}
+}
+
+func (rec *recording) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.w.Close()
+}
+
+// startRecording is a no-op unless recording is enabled for the handler
+// that owns this session; it opens the backing file lazily, once the PTY
+// (and therefore its initial size) exists.
+func (s *Session) startRecording(cols, rows uint16) {
+	if s.recordingCfg == nil || !s.recordingCfg.Enabled || s.recordingCfg.Store == nil {
+		return
+	}
+
+	w, err := s.recordingCfg.Store.Create(s.id)
+	if err != nil {
+		log.Printf("Failed to create recording for session %s: %v", s.id, err)
+		return
+	}
+
+	rec, err := newRecording(w, cols, rows, s.recordingCfg.CaptureInput, s.principal)
+	if err != nil {
+		log.Printf("Failed to start recording for session %s: %v", s.id, err)
+		return
+	}
+
+	s.recording = rec
+}
+
+func (s *Session) stopRecording() {
+	if s.recording != nil {
+		s.recording.Close()
+	}
+}
+
+// DiskRecordingStore is the default RecordingStore, writing one .cast
+// file per session under Dir and pruning by age/total size on Create.
+type DiskRecordingStore struct {
+	Dir          string
+	MaxSizeBytes int64
+	MaxAge       time.Duration
+}
+
+func NewDiskRecordingStore(dir string) *DiskRecordingStore {
+	return &DiskRecordingStore{Dir: dir}
+}
+
+func (s *DiskRecordingStore) path(sessionID string) string {
+	return filepath.Join(s.Dir, sessionID+".cast")
+}
+
+func (s *DiskRecordingStore) Create(sessionID string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	s.prune()
+	return os.Create(s.path(sessionID))
+}
+
+func (s *DiskRecordingStore) Open(sessionID string) (io.ReadSeekCloser, error) {
+	return os.Open(s.path(sessionID))
+}
+
+func (s *DiskRecordingStore) Delete(sessionID string) error {
+	err := os.Remove(s.path(sessionID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *DiskRecordingStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".cast") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), ".cast"))
+	}
+	return ids, nil
+}
+
+// prune removes recordings older than MaxAge and, if the directory still
+// exceeds MaxSizeBytes, deletes the oldest remaining ones until it fits.
+// Either limit set to zero disables that check.
+func (s *DiskRecordingStore) prune() {
+	if s.MaxAge <= 0 && s.MaxSizeBytes <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return
+	}
+
+	type file struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []file
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".cast") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if s.MaxAge > 0 && time.Since(info.ModTime()) > s.MaxAge {
+			os.Remove(filepath.Join(s.Dir, entry.Name()))
+			continue
+		}
+		files = append(files, file{name: entry.Name(), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	if s.MaxSizeBytes <= 0 || total <= s.MaxSizeBytes {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= s.MaxSizeBytes {
+			break
+		}
+		os.Remove(filepath.Join(s.Dir, f.name))
+		total -= f.size
+	}
+}
+
+// ServeRecording streams a session's .cast file, e.g. for download or
+// for offline playback in an asciinema-compatible viewer.
+func (h *Handler) ServeRecording(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.checkAuth(r); err != nil {
+		log.Printf("AUDIT: refusing recording download from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.TrimSuffix(sessionIDFromPath(r.URL.Path), ".cast")
+	if id == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	if h.recording.Store == nil {
+		http.Error(w, "recording not enabled", http.StatusNotFound)
+		return
+	}
+
+	f, err := h.recording.Store.Open(id)
+	if err != nil {
+		http.Error(w, "recording not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	io.Copy(w, f)
+}
+
+// ServeReplay streams a previously recorded session back over a WebSocket
+// as MsgTypeData/MsgTypeResize frames, honoring the original event timing
+// (scaled by ?speed=, default 1) and an optional ?seek= in seconds.
+func (h *Handler) ServeReplay(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.checkAuth(r); err != nil {
+		log.Printf("AUDIT: refusing replay from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := sessionIDFromPath(r.URL.Path)
+	if id == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	if h.recording.Store == nil {
+		http.Error(w, "recording not enabled", http.StatusNotFound)
+		return
+	}
+
+	speed := 1.0
+	if s := r.URL.Query().Get("speed"); s != "" {
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 {
+			speed = v
+		}
+	}
+
+	seek := 0.0
+	if s := r.URL.Query().Get("seek"); s != "" {
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 {
+			seek = v
+		}
+	}
+
+	f, err := h.recording.Store.Open(id)
+	if err != nil {
+		http.Error(w, "recording not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	conn, err := h.wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	replayRecording(conn, f, speed, seek)
+}
+
+func replayRecording(conn *websocket.Conn, f io.Reader, speed, seek float64) {
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	if !scanner.Scan() {
+		return
+	}
+	// First line is the header; replay only needs the events that follow.
+
+	var lastElapsed float64
+	for scanner.Scan() {
+		var event []json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 {
+			continue
+		}
+
+		var elapsed float64
+		var kind string
+		var chunk string
+		json.Unmarshal(event[0], &elapsed)
+		json.Unmarshal(event[1], &kind)
+		json.Unmarshal(event[2], &chunk)
+
+		if elapsed < seek {
+			lastElapsed = elapsed
+			continue
+		}
+
+		if lastElapsed >= seek {
+			if delay := (elapsed - lastElapsed) / speed; delay > 0 {
+				time.Sleep(time.Duration(delay * float64(time.Second)))
+			}
+		}
+		lastElapsed = elapsed
+
+		var msg *Message
+		switch kind {
+		case "o":
+			msg = &Message{Type: MsgTypeData, Data: []byte(chunk)}
+		case "r":
+			parts := strings.SplitN(chunk, "x", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			cols, err1 := strconv.ParseUint(parts[0], 10, 16)
+			rows, err2 := strconv.ParseUint(parts[1], 10, 16)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			resizeData := &ResizeData{Cols: uint16(cols), Rows: uint16(rows)}
+			msg = &Message{Type: MsgTypeResize, Data: resizeData.Marshal()}
+		default:
+			continue
+		}
+
+		data, err := msg.Marshal()
+		if err != nil {
+			continue
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type nopWriteCloser struct{ *bytes.Buffer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestNewRecordingWritesHeaderWithPrincipal(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := newRecording(nopWriteCloser{&buf}, 80, 24, false, &Principal{ID: "alice"})
+	if err != nil {
+		t.Fatalf("newRecording failed: %v", err)
+	}
+	defer rec.Close()
+
+	var header recordingHeader
+	line, _, _ := bytes.Cut(buf.Bytes(), []byte("\n"))
+	if err := json.Unmarshal(line, &header); err != nil {
+		t.Fatalf("failed to unmarshal header: %v", err)
+	}
+	if header.Version != 2 || header.Width != 80 || header.Height != 24 {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+	if header.Env["PRINCIPAL"] != "alice" {
+		t.Fatalf("expected PRINCIPAL=alice in header env, got %q", header.Env["PRINCIPAL"])
+	}
+}
+
+func TestRecordingEventsFormatAndRespectCaptureInput(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := newRecording(nopWriteCloser{&buf}, 80, 24, false, nil)
+	if err != nil {
+		t.Fatalf("newRecording failed: %v", err)
+	}
+	defer rec.Close()
+
+	buf.Reset() // drop the header line, only the events matter here
+
+	rec.output([]byte("hello"))
+	rec.input([]byte("ignored, captureInput is false"))
+	rec.resize(100, 40)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected output+resize events only (input suppressed), got %d lines: %q", len(lines), lines)
+	}
+
+	var outputEvent []interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &outputEvent); err != nil {
+		t.Fatalf("failed to unmarshal output event: %v", err)
+	}
+	if kind := outputEvent[1]; kind != "o" || outputEvent[2] != "hello" {
+		t.Fatalf("unexpected output event: %+v", outputEvent)
+	}
+
+	var resizeEvent []interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &resizeEvent); err != nil {
+		t.Fatalf("failed to unmarshal resize event: %v", err)
+	}
+	if kind := resizeEvent[1]; kind != "r" || resizeEvent[2] != "100x40" {
+		t.Fatalf("unexpected resize event: %+v", resizeEvent)
+	}
+}
+
+func TestDiskRecordingStorePrunesByAgeAndSize(t *testing.T) {
+	dir := t.TempDir()
+	store := &DiskRecordingStore{Dir: dir, MaxAge: time.Hour}
+
+	old := filepath.Join(dir, "old.cast")
+	if err := os.WriteFile(old, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	oldTime := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(old, oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+
+	store.prune()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expected old.cast to be pruned by age, stat err: %v", err)
+	}
+
+	sizeStore := &DiskRecordingStore{Dir: dir, MaxSizeBytes: 10}
+	for _, name := range []string{"a.cast", "b.cast", "c.cast"} {
+		if err := os.WriteFile(filepath.Join(dir, name), bytes.Repeat([]byte("x"), 8), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond) // keep distinct ModTimes for oldest-first pruning
+	}
+
+	sizeStore.prune()
+
+	ids, err := sizeStore.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var total int64
+	for _, id := range ids {
+		info, err := os.Stat(filepath.Join(dir, id+".cast"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += info.Size()
+	}
+	if total > sizeStore.MaxSizeBytes {
+		t.Fatalf("expected pruning to bring total under %d bytes, got %d across %v", sizeStore.MaxSizeBytes, total, ids)
+	}
+}
+
+func TestReplayRecordingParsesEventsIntoMessages(t *testing.T) {
+	cast := strings.Join([]string{
+		`{"version":2,"width":80,"height":24,"timestamp":0,"env":{}}`,
+		`[0, "o", "hi"]`,
+		`[0, "r", "100x40"]`,
+		`[0, "x", "unknown kind is skipped"]`,
+	}, "\n") + "\n"
+
+	var serverConn *websocket.Conn
+	upgraded := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		serverConn = conn
+		close(upgraded)
+	}))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	clientConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer clientConn.Close()
+	<-upgraded
+	defer serverConn.Close()
+
+	go replayRecording(serverConn, strings.NewReader(cast), 1000, 0)
+
+	_, data, err := clientConn.ReadMessage()
+	if err != nil {
+		t.Fatalf("reading first replayed message failed: %v", err)
+	}
+	msg, err := UnmarshalMessage(data)
+	if err != nil {
+		t.Fatalf("failed to unmarshal replayed message: %v", err)
+	}
+	if msg.Type != MsgTypeData || string(msg.Data) != "hi" {
+		t.Fatalf("expected a data message \"hi\", got type=%d data=%q", msg.Type, msg.Data)
+	}
+
+	_, data, err = clientConn.ReadMessage()
+	if err != nil {
+		t.Fatalf("reading second replayed message failed: %v", err)
+	}
+	msg, err = UnmarshalMessage(data)
+	if err != nil {
+		t.Fatalf("failed to unmarshal replayed message: %v", err)
+	}
+	if msg.Type != MsgTypeResize {
+		t.Fatalf("expected a resize message, got type=%d", msg.Type)
+	}
+	resize, err := UnmarshalResizeData(msg.Data)
+	if err != nil || resize.Cols != 100 || resize.Rows != 40 {
+		t.Fatalf("unexpected resize payload: %+v, err=%v", resize, err)
+	}
+}
@@ -0,0 +1,333 @@
+package terminal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// viewerSendBuffer bounds how much unacknowledged PTY output a single
+// viewer can be behind before it's treated as a slow consumer.
+const viewerSendBuffer = 64
+
+// viewer is a read-only (by default) WebSocket spectator attached to a
+// shared Session via ServeJoin. It gets its own send queue so one slow
+// viewer can never block the PTY or the host connection.
+type viewer struct {
+	id   string
+	conn *websocket.Conn
+
+	send chan []byte
+
+	cols, rows uint16
+
+	writeMu sync.Mutex
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func sessionIDFromPath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	parts := strings.Split(path, "/")
+	return parts[len(parts)-1]
+}
+
+// ServeJoin upgrades a spectator connection to an existing session,
+// identified by the trailing path segment, e.g. /ws/terminal/join/{id}.
+func (h *Handler) ServeJoin(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.checkAuth(r); err != nil {
+		log.Printf("AUDIT: refusing join from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID := sessionIDFromPath(r.URL.Path)
+	if sessionID == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	session, ok := h.store.GetSession(sessionID)
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	conn, err := h.wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	h.applyCompression(conn, r)
+
+	session.addViewer(conn)
+}
+
+func (s *Session) addViewer(conn *websocket.Conn) {
+	id, err := newSessionID()
+	if err != nil {
+		log.Printf("Failed to generate viewer id: %v", err)
+		conn.Close()
+		return
+	}
+
+	v := &viewer{
+		id:   id,
+		conn: conn,
+		send: make(chan []byte, viewerSendBuffer),
+	}
+
+	s.viewersMu.Lock()
+	s.viewers[id] = v
+	s.viewersMu.Unlock()
+
+	s.broadcastParticipants()
+
+	go s.viewerWritePump(v)
+	go s.viewerReadPump(v)
+}
+
+func (s *Session) removeViewer(v *viewer) {
+	s.viewersMu.Lock()
+	if _, ok := s.viewers[v.id]; !ok {
+		s.viewersMu.Unlock()
+		return
+	}
+	delete(s.viewers, v.id)
+	s.viewersMu.Unlock()
+
+	close(v.send)
+	v.conn.Close()
+
+	// A departing writer hands control back to the host rather than
+	// leaving the session with no way to regain input.
+	s.roleMu.Lock()
+	if s.writerID == v.id {
+		s.writerID = hostWriterID
+	}
+	s.roleMu.Unlock()
+
+	s.recomputeSize()
+	s.broadcastParticipants()
+}
+
+func (s *Session) closeViewers() {
+	s.viewersMu.Lock()
+	viewers := make([]*viewer, 0, len(s.viewers))
+	for _, v := range s.viewers {
+		viewers = append(viewers, v)
+	}
+	s.viewersMu.Unlock()
+
+	for _, v := range viewers {
+		s.removeViewer(v)
+	}
+}
+
+func (s *Session) viewerWritePump(v *viewer) {
+	for data := range v.send {
+		v.writeMu.Lock()
+		v.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		err := v.conn.WriteMessage(websocket.BinaryMessage, data)
+		v.writeMu.Unlock()
+
+		if err != nil {
+			s.removeViewer(v)
+			return
+		}
+	}
+}
+
+func (s *Session) viewerReadPump(v *viewer) {
+	defer s.removeViewer(v)
+
+	for {
+		_, data, err := v.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if err := s.handleViewerMessage(v, data); err != nil {
+			log.Printf("Viewer message handling error: %v", err)
+		}
+	}
+}
+
+func (s *Session) handleViewerMessage(v *viewer, data []byte) error {
+	msg, err := UnmarshalMessage(data)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+
+	switch msg.Type {
+	case MsgTypeData:
+		if !s.isWriter(v.id) {
+			return nil
+		}
+		return s.handleData(msg.Data)
+	case MsgTypeResize:
+		// Every viewer reports its own window regardless of write role,
+		// since the PTY is sized to the smallest connected terminal.
+		resizeData, err := UnmarshalResizeData(msg.Data)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal resize data: %w", err)
+		}
+		v.cols, v.rows = resizeData.Cols, resizeData.Rows
+		return s.recomputeSize()
+	case MsgTypeControl:
+		if !s.isWriter(v.id) {
+			return nil
+		}
+		return s.handleControl(msg.Data)
+	case MsgTypeRoleGrant:
+		return s.handleRoleChange(v.id, string(msg.Data))
+	case MsgTypeRoleRevoke:
+		return s.handleRoleChange(v.id, hostWriterID)
+	case MsgTypeHeartbeat:
+		return nil
+	default:
+		return fmt.Errorf("unknown message type: %d", msg.Type)
+	}
+}
+
+// handleRoleChange transfers the write lock to target. The host is always
+// authoritative and may reclaim or reassign the write role regardless of
+// who currently holds it; any other requester must currently hold the
+// write lock itself. target must name a connected participant (the host
+// or a connected viewer), so a grant can never hand the lock to nobody.
+func (s *Session) handleRoleChange(requester, target string) error {
+	s.roleMu.Lock()
+	defer s.roleMu.Unlock()
+
+	if requester != hostWriterID && s.writerID != requester {
+		return fmt.Errorf("only the current writer may change the write role")
+	}
+
+	if !s.hasParticipantLocked(target) {
+		return fmt.Errorf("cannot grant write role to unknown participant %q", target)
+	}
+
+	s.writerID = target
+	go s.broadcastParticipants()
+	return nil
+}
+
+// hasParticipantLocked reports whether id names the host or a currently
+// connected viewer. Callers must hold s.roleMu.
+func (s *Session) hasParticipantLocked(id string) bool {
+	if id == hostWriterID {
+		return true
+	}
+	s.viewersMu.RLock()
+	defer s.viewersMu.RUnlock()
+	_, ok := s.viewers[id]
+	return ok
+}
+
+func (s *Session) isWriter(id string) bool {
+	s.roleMu.RLock()
+	defer s.roleMu.RUnlock()
+	return s.writerID == id
+}
+
+// recomputeSize resizes the PTY to the smallest window among the host
+// and every connected viewer, so output never overflows any terminal.
+func (s *Session) recomputeSize() error {
+	if s.pty == nil {
+		return nil
+	}
+
+	s.hostSizeMu.RLock()
+	cols, rows := s.hostCols, s.hostRows
+	s.hostSizeMu.RUnlock()
+
+	s.viewersMu.RLock()
+	for _, v := range s.viewers {
+		if v.cols > 0 && (cols == 0 || v.cols < cols) {
+			cols = v.cols
+		}
+		if v.rows > 0 && (rows == 0 || v.rows < rows) {
+			rows = v.rows
+		}
+	}
+	s.viewersMu.RUnlock()
+
+	if cols == 0 || rows == 0 {
+		return nil
+	}
+
+	return s.pty.Resize(cols, rows)
+}
+
+// broadcastToViewers fans a message out to every connected viewer,
+// dropping it for any viewer whose send queue is already full rather
+// than blocking the PTY read loop on a slow consumer.
+func (s *Session) broadcastToViewers(msg *Message) {
+	s.viewersMu.RLock()
+	defer s.viewersMu.RUnlock()
+
+	if len(s.viewers) == 0 {
+		return
+	}
+
+	data, err := msg.Marshal()
+	if err != nil {
+		log.Printf("Failed to marshal message: %v", err)
+		return
+	}
+
+	for _, v := range s.viewers {
+		select {
+		case v.send <- data:
+		default:
+			log.Printf("Dropping message for slow viewer %s", v.id)
+		}
+	}
+}
+
+func (s *Session) broadcastParticipants() {
+	s.roleMu.RLock()
+	writerID := s.writerID
+	s.roleMu.RUnlock()
+
+	participants := []Participant{{ID: hostWriterID, Role: roleOf(hostWriterID, writerID)}}
+
+	s.viewersMu.RLock()
+	for id := range s.viewers {
+		participants = append(participants, Participant{ID: id, Role: roleOf(id, writerID)})
+	}
+	s.viewersMu.RUnlock()
+
+	data, err := MarshalParticipants(participants)
+	if err != nil {
+		log.Printf("Failed to marshal participants: %v", err)
+		return
+	}
+
+	msg := &Message{Type: MsgTypeParticipants, Data: data}
+	s.sendMessage(msg)
+	s.broadcastToViewers(msg)
+}
+
+func roleOf(id, writerID string) string {
+	if id == writerID {
+		return "writer"
+	}
+	return "viewer"
+}
@@ -0,0 +1,106 @@
+package terminal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestHostSession dials a real WebSocket so Session.sendMessage (invoked
+// from handleRoleChange's broadcastParticipants) has a live connection to
+// write to, without spinning up a PTY.
+func newTestHostSession(t *testing.T) (*Session, func()) {
+	t.Helper()
+
+	var serverConn *websocket.Conn
+	upgraded := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		serverConn = conn
+		close(upgraded)
+	}))
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	clientConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	<-upgraded
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Session{
+		conn:      serverConn,
+		ctx:       ctx,
+		cancel:    cancel,
+		writerID:  hostWriterID,
+		viewers:   make(map[string]*viewer),
+		metrics:   noopMetrics{},
+		outputBuf: newOutputRing(0),
+	}
+
+	cleanup := func() {
+		cancel()
+		clientConn.Close()
+		serverConn.Close()
+		srv.Close()
+	}
+	return s, cleanup
+}
+
+func TestHandleRoleChangeHostCanReclaimFromViewer(t *testing.T) {
+	s, cleanup := newTestHostSession(t)
+	defer cleanup()
+
+	s.viewersMu.Lock()
+	s.viewers["viewer1"] = &viewer{id: "viewer1", send: make(chan []byte, viewerSendBuffer)}
+	s.viewersMu.Unlock()
+
+	if err := s.handleRoleChange(hostWriterID, "viewer1"); err != nil {
+		t.Fatalf("host grant failed: %v", err)
+	}
+	if !s.isWriter("viewer1") {
+		t.Fatalf("expected viewer1 to hold the write role after grant")
+	}
+
+	// The host must always be able to reclaim the role, even though it is
+	// no longer the current writer.
+	if err := s.handleRoleChange(hostWriterID, hostWriterID); err != nil {
+		t.Fatalf("host revoke should always succeed, got: %v", err)
+	}
+	if !s.isWriter(hostWriterID) {
+		t.Fatalf("expected host to hold the write role after reclaiming it")
+	}
+}
+
+func TestHandleRoleChangeRejectsUnknownTarget(t *testing.T) {
+	s, cleanup := newTestHostSession(t)
+	defer cleanup()
+
+	if err := s.handleRoleChange(hostWriterID, "no-such-viewer"); err == nil {
+		t.Fatalf("expected granting to an unknown participant to fail")
+	}
+	if !s.isWriter(hostWriterID) {
+		t.Fatalf("write role should be unchanged after a rejected grant")
+	}
+}
+
+func TestHandleRoleChangeNonWriterCannotChangeRole(t *testing.T) {
+	s, cleanup := newTestHostSession(t)
+	defer cleanup()
+
+	s.viewersMu.Lock()
+	s.viewers["viewer1"] = &viewer{id: "viewer1", send: make(chan []byte, viewerSendBuffer)}
+	s.viewersMu.Unlock()
+
+	if err := s.handleRoleChange("viewer1", "viewer1"); err == nil {
+		t.Fatalf("expected a non-writer, non-host viewer to be rejected")
+	}
+}
@@ -0,0 +1,115 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ShellProfile constrains what DefaultPTYProvider is allowed to spawn: the
+// command and its arguments, an env allow/denylist layered on top of
+// os.Environ(), a working directory, an optional uid/gid to drop
+// privileges to on non-Windows platforms, resource limits applied via
+// prlimit, and an idle timeout that kills the child after N seconds
+// without input.
+type ShellProfile struct {
+	Name    string
+	Command string
+	Args    []string
+
+	EnvAllowlist []string
+	EnvDenylist  []string
+	Dir          string
+
+	UID *uint32
+	GID *uint32
+
+	RLimitCPU    uint64 // seconds, 0 = unset
+	RLimitAS     uint64 // bytes, 0 = unset
+	RLimitNOFILE uint64 // open file descriptors, 0 = unset
+
+	IdleTimeout time.Duration
+}
+
+// ProfileResolver maps an inbound HTTP request to the name of the
+// ShellProfile it should be bound to, so embedders can plug in their own
+// authentication/authorization before a PTY is ever spawned.
+type ProfileResolver interface {
+	Resolve(r *http.Request) (string, error)
+}
+
+// QueryHeaderProfileResolver is the default ProfileResolver: it reads the
+// profile name from a query param (default "profile") or, failing that,
+// a header (default "X-Shell-Profile").
+type QueryHeaderProfileResolver struct {
+	QueryParam string
+	HeaderName string
+}
+
+func NewQueryHeaderProfileResolver() *QueryHeaderProfileResolver {
+	return &QueryHeaderProfileResolver{QueryParam: "profile", HeaderName: "X-Shell-Profile"}
+}
+
+func (r *QueryHeaderProfileResolver) Resolve(req *http.Request) (string, error) {
+	if name := req.URL.Query().Get(r.QueryParam); name != "" {
+		return name, nil
+	}
+	if name := req.Header.Get(r.HeaderName); name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("no shell profile requested")
+}
+
+type profileContextKey struct{}
+
+func contextWithProfile(ctx context.Context, profile *ShellProfile) context.Context {
+	return context.WithValue(ctx, profileContextKey{}, profile)
+}
+
+func profileFromContext(ctx context.Context) (*ShellProfile, bool) {
+	profile, ok := ctx.Value(profileContextKey{}).(*ShellProfile)
+	return profile, ok
+}
+
+// resolveProfile looks up the ShellProfile for an inbound request. It
+// returns (nil, nil) when no ProfileResolver is configured, preserving the
+// handler's legacy behavior of spawning $SHELL with the full parent env.
+// Once a resolver is configured, any name it can't find in h.profiles is
+// refused rather than silently falling back. principal is only used to tie
+// the refusal to an identity in the audit log.
+func (h *Handler) resolveProfile(r *http.Request, principal *Principal) (*ShellProfile, error) {
+	if h.profileResolver == nil {
+		return nil, nil
+	}
+
+	name, err := h.profileResolver.Resolve(r)
+	if err != nil {
+		return nil, fmt.Errorf("resolving shell profile: %w", err)
+	}
+
+	profile, ok := h.profiles[name]
+	if !ok {
+		log.Printf("AUDIT: refusing connection for principal %s, unknown shell profile %q", principalID(principal), name)
+		return nil, fmt.Errorf("unknown shell profile %q", name)
+	}
+
+	return profile, nil
+}
+
+// WithShellProfiles enables the profile subsystem: inbound connections must
+// resolve, via resolver, to one of the named profiles or the upgrade is
+// refused.
+func (h *Handler) WithShellProfiles(resolver ProfileResolver, profiles map[string]*ShellProfile) *Handler {
+	h.profileResolver = resolver
+	h.profiles = profiles
+	return h
+}
+
+// WithPTYProvider overrides the PTYProvider used to spawn PTYs, e.g. to
+// stub it out in tests.
+func (h *Handler) WithPTYProvider(provider PTYProvider) *Handler {
+	h.ptyProvider = provider
+	return h
+}
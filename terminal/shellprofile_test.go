@@ -0,0 +1,62 @@
+package terminal
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestFilterEnvAllowlistAndDenylist(t *testing.T) {
+	base := []string{"PATH=/usr/bin", "SECRET=xyz", "HOME=/root", "LANG=C"}
+
+	if got := filterEnv(base, nil, nil); !reflect.DeepEqual(got, base) {
+		t.Fatalf("expected no allow/denylist to pass base through unchanged, got %v", got)
+	}
+
+	got := filterEnv(base, nil, []string{"SECRET"})
+	want := []string{"PATH=/usr/bin", "HOME=/root", "LANG=C"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("denylist: got %v, want %v", got, want)
+	}
+
+	got = filterEnv(base, []string{"PATH", "HOME"}, nil)
+	want = []string{"PATH=/usr/bin", "HOME=/root"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("allowlist: got %v, want %v", got, want)
+	}
+
+	// A key on both lists is denied: denylist wins.
+	got = filterEnv(base, []string{"PATH", "SECRET"}, []string{"SECRET"})
+	want = []string{"PATH=/usr/bin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("allowlist+denylist conflict: got %v, want %v", got, want)
+	}
+}
+
+func TestWithRLimitsWrapsInPrlimit(t *testing.T) {
+	profile := &ShellProfile{RLimitCPU: 5, RLimitAS: 1 << 20, RLimitNOFILE: 64}
+
+	name, args := withRLimits(profile, "bash", []string{"-c", "echo hi"})
+	if runtime.GOOS == "windows" {
+		if name != "bash" {
+			t.Fatalf("expected no wrapping on windows, got %q", name)
+		}
+		return
+	}
+
+	if name != "prlimit" {
+		t.Fatalf("expected the command to be wrapped in prlimit, got %q", name)
+	}
+	want := []string{"--cpu=5", "--as=1048576", "--nofile=64", "--", "bash", "-c", "echo hi"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("got args %v, want %v", args, want)
+	}
+}
+
+func TestWithRLimitsNoopWithoutLimits(t *testing.T) {
+	profile := &ShellProfile{}
+	name, args := withRLimits(profile, "bash", []string{"-c", "echo hi"})
+	if name != "bash" || !reflect.DeepEqual(args, []string{"-c", "echo hi"}) {
+		t.Fatalf("expected passthrough with no limits configured, got name=%q args=%v", name, args)
+	}
+}